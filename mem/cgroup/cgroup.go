@@ -0,0 +1,333 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cgroup gets and processes memory usage and limits from the
+// calling process's own cgroup, rather than the host-wide view exposed
+// by /proc/meminfo.
+//
+// Both cgroup v2 (the unified hierarchy, memory.current/memory.max/
+// memory.stat) and cgroup v1 (memory.usage_in_bytes/memory.limit_in_bytes/
+// memory.stat under the "memory" controller) are supported; Auto detects
+// which one the host is running and resolves the caller's own cgroup out
+// of /proc/self/cgroup.
+package cgroup
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	joe "github.com/mohae/joefriday"
+	"github.com/mohae/joefriday/mem"
+)
+
+// Version identifies which cgroup hierarchy a Source reads from.
+type Version int
+
+const (
+	// V2 is the unified cgroup hierarchy (memory.current, memory.max,
+	// memory.stat).
+	V2 Version = iota
+	// V1 is the legacy per-controller hierarchy (memory.usage_in_bytes,
+	// memory.limit_in_bytes, memory.stat).
+	V1
+)
+
+// Info holds the cgroup memory accounting this package knows how to read.
+// Usage and Limit come straight from memory.current/memory.max (v2) or
+// memory.usage_in_bytes/memory.limit_in_bytes (v1); the rest come from
+// memory.stat, whose key names (and presence) vary a bit between v1 and
+// v2, so not every field is populated under every Version.
+type Info struct {
+	Timestamp    int64
+	Usage        int64
+	Limit        int64
+	Cache        int64
+	RSS          int64
+	RSSHuge      int64
+	Shmem        int64
+	MappedFile   int64
+	Dirty        int64
+	Writeback    int64
+	InactiveAnon int64
+	ActiveAnon   int64
+	InactiveFile int64
+	ActiveFile   int64
+	Unevictable  int64
+}
+
+// field pairs a memory.stat key with the setter that copies its value
+// onto an *Info; see mem.RegisterField, which this mirrors.
+type field struct {
+	name string
+	set  func(*Info, int64)
+}
+
+// fields is the memory.stat key registry, shared by both cgroup
+// versions; a registered key that a version's memory.stat doesn't
+// contain leaves its field zero.
+var fields = map[string]field{}
+
+// RegisterField registers a setter for the named memory.stat key, so
+// that GetInfo and DataTicker populate it without a change to this
+// package.  Registering a known name replaces its setter.
+func RegisterField(name string, setter func(*Info, int64)) {
+	fields[name] = field{name: name, set: setter}
+}
+
+func init() {
+	RegisterField("cache", func(i *Info, v int64) { i.Cache = v })
+	RegisterField("rss", func(i *Info, v int64) { i.RSS = v })
+	RegisterField("rss_huge", func(i *Info, v int64) { i.RSSHuge = v })
+	RegisterField("shmem", func(i *Info, v int64) { i.Shmem = v })
+	RegisterField("mapped_file", func(i *Info, v int64) { i.MappedFile = v })
+	RegisterField("dirty", func(i *Info, v int64) { i.Dirty = v })
+	RegisterField("writeback", func(i *Info, v int64) { i.Writeback = v })
+	RegisterField("inactive_anon", func(i *Info, v int64) { i.InactiveAnon = v })
+	RegisterField("active_anon", func(i *Info, v int64) { i.ActiveAnon = v })
+	RegisterField("inactive_file", func(i *Info, v int64) { i.InactiveFile = v })
+	RegisterField("active_file", func(i *Info, v int64) { i.ActiveFile = v })
+	RegisterField("unevictable", func(i *Info, v int64) { i.Unevictable = v })
+}
+
+// toMemInfo maps i onto the fields of mem.Info closest in meaning, so
+// that a cgroup Source can reuse mem.Info's existing flatbuffers
+// Serialize rather than defining its own wire format.  Fields with no
+// sensible cgroup analog (e.g. SwapCached) are left zero.
+func (i *Info) toMemInfo() *mem.Info {
+	return &mem.Info{
+		Timestamp:    i.Timestamp,
+		MemTotal:     int(i.Limit),
+		MemFree:      int(i.Limit - i.Usage),
+		MemAvailable: int(i.Limit - i.Usage),
+		Cached:       int(i.Cache),
+		Active:       int(i.ActiveAnon + i.ActiveFile),
+		Inactive:     int(i.InactiveAnon + i.InactiveFile),
+		Unevictable:  int(i.Unevictable),
+		AnonPages:    int(i.RSS),
+		Shmem:        int(i.Shmem),
+		Mapped:       int(i.MappedFile),
+		Dirty:        int(i.Dirty),
+		Writeback:    int(i.Writeback),
+	}
+}
+
+// Source reads memory accounting from a single resolved cgroup
+// directory.  Use Auto to build one for the calling process's own
+// cgroup; New builds one against an arbitrary directory, which is
+// mainly useful for reading a different container's cgroup or for
+// tests.
+type Source struct {
+	dir     string
+	version Version
+}
+
+// Source satisfies mem.Source, so code written against that interface
+// works against a cgroup-scoped Source without change.
+var _ mem.Source = (*Source)(nil)
+
+// New returns a Source that reads the memory controller rooted at dir
+// using the given Version.
+func New(dir string, version Version) *Source {
+	return &Source{dir: dir, version: version}
+}
+
+// Auto detects whether the host is running the unified (v2) or legacy
+// (v1) cgroup hierarchy and resolves the calling process's own cgroup
+// out of /proc/self/cgroup, returning a Source for it.
+func Auto() (*Source, error) {
+	if detectVersion("/sys/fs/cgroup") == V2 {
+		dir, err := ownCgroupDir("/sys/fs/cgroup", "")
+		if err != nil {
+			return nil, err
+		}
+		return New(dir, V2), nil
+	}
+
+	dir, err := ownCgroupDir("/sys/fs/cgroup/memory", "memory")
+	if err != nil {
+		return nil, err
+	}
+	return New(dir, V1), nil
+}
+
+// detectVersion reports which cgroup hierarchy is mounted at root
+// (normally /sys/fs/cgroup).  v2 is identified by cgroup.controllers, a
+// file the unified hierarchy always exposes at its root; per-controller
+// leaf knobs like memory.max generally aren't present there, since the
+// root itself has no parent to be limited by.
+func detectVersion(root string) Version {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return V2
+	}
+	return V1
+}
+
+// ownCgroupDir resolves the calling process's cgroup path for the given
+// controller (empty for the unified v2 hierarchy) out of
+// /proc/self/cgroup and joins it onto root.
+func ownCgroupDir(root, controller string) (string, error) {
+	buf, err := ioutil.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		// Each line is "hierarchy-ID:controller-list:path".
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		controllers := strings.Split(fields[1], ",")
+		if !hasController(controllers, controller) {
+			continue
+		}
+		return filepath.Join(root, fields[2]), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", &os.PathError{Op: "resolve cgroup", Path: "/proc/self/cgroup", Err: os.ErrNotExist}
+}
+
+// hasController reports whether controller is present in controllers,
+// or, for the unified (v2) hierarchy, whether the line itself is the
+// single unnamed entry ("0::/...") that v2 uses.
+func hasController(controllers []string, controller string) bool {
+	if controller == "" {
+		return len(controllers) == 1 && controllers[0] == ""
+	}
+	for _, c := range controllers {
+		if c == controller {
+			return true
+		}
+	}
+	return false
+}
+
+// GetInfo returns the current memory accounting for s's cgroup.
+func (s *Source) GetInfo() (*Info, error) {
+	i := &Info{Timestamp: time.Now().UTC().UnixNano()}
+
+	usageFile, limitFile := "memory.current", "memory.max"
+	if s.version == V1 {
+		usageFile, limitFile = "memory.usage_in_bytes", "memory.limit_in_bytes"
+	}
+
+	usage, err := readInt64(filepath.Join(s.dir, usageFile))
+	if err != nil {
+		return nil, err
+	}
+	i.Usage = usage
+
+	limit, err := readInt64(filepath.Join(s.dir, limitFile))
+	if err != nil {
+		return nil, err
+	}
+	i.Limit = limit
+
+	stat, err := ioutil.ReadFile(filepath.Join(s.dir, "memory.stat"))
+	if err != nil {
+		return nil, err
+	}
+	if err := parseStat(stat, i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// GetData returns the current cgroup memory info, mapped onto mem.Info
+// and encoded with enc, so it's interchangeable with mem.GetData's
+// output.
+func (s *Source) GetData(enc mem.Encoder) ([]byte, error) {
+	i, err := s.GetInfo()
+	if err != nil {
+		return nil, err
+	}
+	return enc.Encode(i.toMemInfo()), nil
+}
+
+// DataTicker gathers this cgroup's memory info on a ticker and sends the
+// result, encoded with enc, to outCh, mirroring mem.DataTicker's
+// semantics: closing or sending on done exits the loop, outCh is closed
+// on exit, and errors are sent to errCh without stopping the loop.  If
+// hist is non-nil, every sample is also recorded with hist.Add.
+func (s *Source) DataTicker(interval time.Duration, enc mem.Encoder, hist *mem.History, outCh chan []byte, done chan struct{}, errCh chan error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer close(outCh)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			i, err := s.GetInfo()
+			if err != nil {
+				errCh <- joe.Error{Type: "cgroup", Op: "get memory info", Err: err}
+				continue
+			}
+
+			mi := i.toMemInfo()
+			if hist != nil {
+				hist.Add(mi)
+			}
+			outCh <- enc.Encode(mi)
+		}
+	}
+}
+
+// readInt64 reads a file holding a single integer value, as cgroupfs
+// knobs like memory.current do.  A v2 "max" (no limit set) is reported
+// as the max int64 so callers can treat it uniformly with a numeric
+// limit.
+func readInt64(path string) (int64, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(buf))
+	if s == "max" {
+		return 1<<63 - 1, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// parseStat decodes a memory.stat file's "key value" lines into i,
+// dispatching through the fields registry the same way mem.parse does
+// for /proc/meminfo.
+func parseStat(buf []byte, i *Info) error {
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fld, ok := fields[parts[0]]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			return err
+		}
+		fld.set(i, v)
+	}
+	return scanner.Err()
+}
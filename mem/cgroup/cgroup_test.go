@@ -0,0 +1,95 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroup
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectVersion(t *testing.T) {
+	v2 := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(v2, "cgroup.controllers"), []byte("cpu memory"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := detectVersion(v2); got != V2 {
+		t.Errorf("detectVersion(%s) = %v, want V2", v2, got)
+	}
+
+	v1 := t.TempDir()
+	if got := detectVersion(v1); got != V1 {
+		t.Errorf("detectVersion(%s) = %v, want V1", v1, got)
+	}
+}
+
+func TestHasController(t *testing.T) {
+	tests := []struct {
+		name        string
+		controllers []string
+		controller  string
+		want        bool
+	}{
+		{"v1 match", []string{"memory"}, "memory", true},
+		{"v1 no match", []string{"cpu", "cpuacct"}, "memory", false},
+		{"v2 unified entry", []string{""}, "", true},
+		{"v2 non-unified entry", []string{"memory"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasController(tt.controllers, tt.controller); got != tt.want {
+				t.Errorf("hasController(%v, %q) = %v, want %v", tt.controllers, tt.controller, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	buf := []byte("cache 1048576\nrss 2097152\nrss_huge 0\nshmem 4096\nmapped_file 8192\ndirty 0\nwriteback 0\ninactive_anon 1024\nactive_anon 2048\ninactive_file 4096\nactive_file 8192\nunevictable 512\nunknown_future_key 999\n")
+
+	i := &Info{}
+	if err := parseStat(buf, i); err != nil {
+		t.Fatalf("parseStat: unexpected error: %v", err)
+	}
+
+	want := Info{
+		Cache:        1048576,
+		RSS:          2097152,
+		Shmem:        4096,
+		MappedFile:   8192,
+		InactiveAnon: 1024,
+		ActiveAnon:   2048,
+		InactiveFile: 4096,
+		ActiveFile:   8192,
+		Unevictable:  512,
+	}
+	if *i != want {
+		t.Errorf("parseStat got %+v, want %+v", *i, want)
+	}
+}
+
+func TestReadInt64Max(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "memory.max")
+	if err := ioutil.WriteFile(path, []byte("max\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readInt64(path)
+	if err != nil {
+		t.Fatalf("readInt64: unexpected error: %v", err)
+	}
+	if got != 1<<63-1 {
+		t.Errorf("readInt64(%q) = %d, want max int64", path, got)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mem
+
+// Encoder converts an Info to and from a wire format.  GetData and
+// DataTicker take an Encoder so callers can choose the wire format.
+type Encoder interface {
+	Encode(*Info) []byte
+	Decode([]byte) (*Info, error)
+}
+
+// FlatbuffersEncoder encodes an Info as flatbuffers-serialized Data; it's
+// the format GetData and DataTicker used before Encoder existed.
+type FlatbuffersEncoder struct{}
+
+// Encode implements Encoder.
+func (FlatbuffersEncoder) Encode(i *Info) []byte { return i.Serialize() }
+
+// Decode implements Encoder.
+func (FlatbuffersEncoder) Decode(p []byte) (*Info, error) { return Deserialize(p), nil }
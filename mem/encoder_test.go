@@ -0,0 +1,102 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mem
+
+import (
+	"reflect"
+	"testing"
+)
+
+// populatedInfo returns an Info with every field but Extra set to a
+// distinct, non-zero value, so a round trip that drops or transposes a
+// field shows up as a mismatch rather than a coincidental zero.
+func populatedInfo() *Info {
+	return &Info{
+		Timestamp:         1,
+		MemTotal:          2,
+		MemFree:           3,
+		MemAvailable:      4,
+		Buffers:           5,
+		Cached:            6,
+		SwapCached:        7,
+		Active:            8,
+		Inactive:          9,
+		Unevictable:       10,
+		Mlocked:           11,
+		SwapTotal:         12,
+		SwapFree:          13,
+		Dirty:             14,
+		Writeback:         15,
+		AnonPages:         16,
+		Mapped:            17,
+		Shmem:             18,
+		KReclaimable:      19,
+		Slab:              20,
+		SReclaimable:      21,
+		SUnreclaim:        22,
+		KernelStack:       23,
+		PageTables:        24,
+		Bounce:            25,
+		CommitLimit:       26,
+		CommittedAS:       27,
+		VmallocTotal:      28,
+		VmallocUsed:       29,
+		VmallocChunk:      30,
+		HardwareCorrupted: 31,
+		AnonHugePages:     32,
+		HugePagesTotal:    33,
+		HugePagesFree:     34,
+		HugePagesRsvd:     35,
+		HugePagesSurp:     36,
+		Hugepagesize:      37,
+		DirectMap4k:       38,
+		DirectMap2M:       39,
+		DirectMap1G:       40,
+	}
+}
+
+func TestJSONEncoderRoundTrip(t *testing.T) {
+	want := populatedInfo()
+
+	enc := JSONEncoder{}
+	got, err := enc.Decode(enc.Encode(want))
+	if err != nil {
+		t.Fatalf("Decode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip got %+v, want %+v", got, want)
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	want := populatedInfo()
+
+	got := pbToInfo(infoToPB(want))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("infoToPB/pbToInfo round trip got %+v, want %+v", got, want)
+	}
+}
+
+func TestFlatbuffersEncoderRoundTrip(t *testing.T) {
+	want := populatedInfo()
+
+	enc := FlatbuffersEncoder{}
+	got, err := enc.Decode(enc.Encode(want))
+	if err != nil {
+		t.Fatalf("Decode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mem
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AggFunc reduces the values a single Info field took across a bucket of
+// samples down to one representative value, for History.Downsample.
+type AggFunc func(values []int64) int64
+
+// Min, Max, Avg and P95 are the AggFuncs History.Downsample is meant to
+// be used with.  An empty values slice is a programmer error (Downsample
+// never calls an AggFunc with one) and panics.
+func Min(values []int64) int64 {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func Max(values []int64) int64 {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
+
+func Avg(values []int64) int64 {
+	var sum int64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / int64(len(values))
+}
+
+func P95(values []int64) int64 {
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := (len(sorted)*95+99)/100 - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// History is a bounded, thread-safe ring buffer of recent Info samples.
+// DataTicker and RateTicker write into one when given one; query it with
+// Range, Latest or Downsample.  Add never blocks, so a slow history
+// reader can't put back-pressure on the ticker loop.
+type History struct {
+	mu      sync.Mutex
+	samples []*Info
+	head    int
+	size    int
+}
+
+// NewHistory returns a History that retains up to capacity samples,
+// discarding the oldest once full.  It panics if capacity is not
+// positive.
+func NewHistory(capacity int) *History {
+	if capacity <= 0 {
+		panic("mem: NewHistory: capacity must be > 0")
+	}
+	return &History{samples: make([]*Info, capacity)}
+}
+
+// Add records i as the newest sample, discarding the oldest sample if
+// the History is at capacity.
+func (h *History) Add(i *Info) {
+	h.mu.Lock()
+	n := len(h.samples)
+	h.samples[h.head] = i
+	h.head = (h.head + 1) % n
+	if h.size < n {
+		h.size++
+	}
+	h.mu.Unlock()
+}
+
+// Latest returns the most recently added sample, or nil if History is
+// empty.
+func (h *History) Latest() *Info {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.size == 0 {
+		return nil
+	}
+	return h.samples[(h.head-1+len(h.samples))%len(h.samples)]
+}
+
+// snapshot returns the retained samples oldest-to-newest.  Callers must
+// hold h.mu.
+func (h *History) snapshot() []*Info {
+	out := make([]*Info, h.size)
+	start := (h.head - h.size + len(h.samples)) % len(h.samples)
+	for i := range out {
+		out[i] = h.samples[(start+i)%len(h.samples)]
+	}
+	return out
+}
+
+// Range returns the retained samples whose Timestamp falls within
+// [from, to], oldest first.
+func (h *History) Range(from, to time.Time) []*Info {
+	h.mu.Lock()
+	all := h.snapshot()
+	h.mu.Unlock()
+
+	lo, hi := from.UnixNano(), to.UnixNano()
+	out := make([]*Info, 0, len(all))
+	for _, s := range all {
+		if s.Timestamp >= lo && s.Timestamp <= hi {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Downsample groups the retained samples into consecutive, non-
+// overlapping windows of length bucket and reduces each window to a
+// single Info with agg applied field-by-field, oldest bucket first.
+// Each returned Info's Timestamp is its bucket's last sample's
+// Timestamp.
+func (h *History) Downsample(bucket time.Duration, agg AggFunc) []*Info {
+	h.mu.Lock()
+	all := h.snapshot()
+	h.mu.Unlock()
+
+	if len(all) == 0 || bucket <= 0 {
+		return nil
+	}
+
+	var out []*Info
+	windowStart := time.Unix(0, all[0].Timestamp).Truncate(bucket)
+	window := all[:0:0]
+	for _, s := range all {
+		ts := time.Unix(0, s.Timestamp)
+		if ts.Sub(windowStart) >= bucket {
+			out = append(out, reduce(window, agg))
+			window = nil
+			windowStart = ts.Truncate(bucket)
+		}
+		window = append(window, s)
+	}
+	if len(window) > 0 {
+		out = append(out, reduce(window, agg))
+	}
+	return out
+}
+
+// reduce applies agg to every int field of Info but Timestamp across
+// window, which must be non-empty.  Non-int fields aren't aggregated:
+// Timestamp (an int64) is set to the window's last sample's Timestamp,
+// and Extra (a map) is left nil.
+func reduce(window []*Info, agg AggFunc) *Info {
+	out := &Info{Timestamp: window[len(window)-1].Timestamp}
+	ov := reflect.ValueOf(out).Elem()
+	t := ov.Type()
+
+	values := make([]int64, len(window))
+	for fi := 0; fi < t.NumField(); fi++ {
+		if t.Field(fi).Type.Kind() != reflect.Int {
+			continue
+		}
+		for wi, s := range window {
+			values[wi] = reflect.ValueOf(s).Elem().Field(fi).Int()
+		}
+		ov.Field(fi).SetInt(agg(values))
+	}
+	return out
+}
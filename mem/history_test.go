@@ -0,0 +1,117 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mem
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewHistoryNonPositiveCapacityPanics(t *testing.T) {
+	for _, capacity := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewHistory(%d) did not panic", capacity)
+				}
+			}()
+			NewHistory(capacity)
+		}()
+	}
+}
+
+func TestHistoryAddWraparound(t *testing.T) {
+	h := NewHistory(3)
+	if got := h.Latest(); got != nil {
+		t.Fatalf("Latest() on empty History = %v, want nil", got)
+	}
+
+	for ts := int64(1); ts <= 5; ts++ {
+		h.Add(&Info{Timestamp: ts})
+	}
+
+	// Capacity is 3, so only timestamps 3, 4, 5 should remain.
+	got := h.Latest()
+	if got == nil || got.Timestamp != 5 {
+		t.Fatalf("Latest() = %v, want Timestamp 5", got)
+	}
+
+	all := h.Range(time.Unix(0, 0), time.Unix(0, 10))
+	if len(all) != 3 {
+		t.Fatalf("Range returned %d samples, want 3", len(all))
+	}
+	for i, want := range []int64{3, 4, 5} {
+		if all[i].Timestamp != want {
+			t.Errorf("Range()[%d].Timestamp = %d, want %d", i, all[i].Timestamp, want)
+		}
+	}
+}
+
+func TestHistoryRange(t *testing.T) {
+	h := NewHistory(10)
+	for ts := int64(0); ts < 5; ts++ {
+		h.Add(&Info{Timestamp: ts})
+	}
+
+	got := h.Range(time.Unix(0, 1), time.Unix(0, 3))
+	if len(got) != 3 {
+		t.Fatalf("Range(1, 3) returned %d samples, want 3", len(got))
+	}
+	for i, want := range []int64{1, 2, 3} {
+		if got[i].Timestamp != want {
+			t.Errorf("Range(1, 3)[%d].Timestamp = %d, want %d", i, got[i].Timestamp, want)
+		}
+	}
+}
+
+func TestHistoryDownsample(t *testing.T) {
+	h := NewHistory(10)
+	bucket := time.Second
+	// Two samples in bucket 0, two samples in bucket 1.
+	h.Add(&Info{Timestamp: int64(0 * time.Millisecond), MemFree: 10})
+	h.Add(&Info{Timestamp: int64(500 * time.Millisecond), MemFree: 20})
+	h.Add(&Info{Timestamp: int64(1100 * time.Millisecond), MemFree: 100})
+	h.Add(&Info{Timestamp: int64(1600 * time.Millisecond), MemFree: 200})
+
+	got := h.Downsample(bucket, Max)
+	if len(got) != 2 {
+		t.Fatalf("Downsample returned %d buckets, want 2", len(got))
+	}
+	if got[0].MemFree != 20 {
+		t.Errorf("bucket 0 Max MemFree = %d, want 20", got[0].MemFree)
+	}
+	if got[1].MemFree != 200 {
+		t.Errorf("bucket 1 Max MemFree = %d, want 200", got[1].MemFree)
+	}
+}
+
+func TestAggFuncs(t *testing.T) {
+	values := []int64{5, 1, 9, 3, 7}
+	if got := Min(values); got != 1 {
+		t.Errorf("Min = %d, want 1", got)
+	}
+	if got := Max(values); got != 9 {
+		t.Errorf("Max = %d, want 9", got)
+	}
+	if got := Avg(values); got != 5 {
+		t.Errorf("Avg = %d, want 5", got)
+	}
+}
+
+func TestP95(t *testing.T) {
+	values := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if got := P95(values); got != 10 {
+		t.Errorf("P95 = %d, want 10", got)
+	}
+}
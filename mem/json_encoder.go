@@ -0,0 +1,42 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mem
+
+import "encoding/json"
+
+// JSONEncoder encodes an Info as JSON, for debugging and human-readable
+// output.
+type JSONEncoder struct{}
+
+// Encode implements Encoder.  An error from json.Marshal is a
+// programmer error, since Info holds only int64/int fields and a
+// map[string]uint64, so
+// Encode panics rather than returning an error Encoder.Encode has no way
+// to report.
+func (JSONEncoder) Encode(i *Info) []byte {
+	p, err := json.Marshal(i)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Decode implements Encoder.
+func (JSONEncoder) Decode(p []byte) (*Info, error) {
+	i := &Info{}
+	if err := json.Unmarshal(p, i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
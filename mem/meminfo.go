@@ -16,13 +16,9 @@
 package mem
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/SermoDigital/helpers"
@@ -31,37 +27,198 @@ import (
 	joe "github.com/mohae/joefriday"
 )
 
+// Info holds the fields of /proc/meminfo that this package knows how to
+// populate out of the box.  The set is not exhaustive of everything a given
+// kernel may expose; RegisterField lets a caller retarget a known
+// /proc/meminfo key onto a different field, or, via ExtraField, onto
+// Extra for a key this package has no dedicated field for.
 type Info struct {
-	Timestamp    int64
-	MemTotal     int
-	MemFree      int
-	MemAvailable int
-	Buffers      int
-	Cached       int
-	SwapCached   int
-	Active       int
-	Inactive     int
-	SwapTotal    int
-	SwapFree     int
+	Timestamp         int64
+	MemTotal          int
+	MemFree           int
+	MemAvailable      int
+	Buffers           int
+	Cached            int
+	SwapCached        int
+	Active            int
+	Inactive          int
+	Unevictable       int
+	Mlocked           int
+	SwapTotal         int
+	SwapFree          int
+	Dirty             int
+	Writeback         int
+	AnonPages         int
+	Mapped            int
+	Shmem             int
+	KReclaimable      int
+	Slab              int
+	SReclaimable      int
+	SUnreclaim        int
+	KernelStack       int
+	PageTables        int
+	Bounce            int
+	CommitLimit       int
+	CommittedAS       int
+	VmallocTotal      int
+	VmallocUsed       int
+	VmallocChunk      int
+	HardwareCorrupted int
+	AnonHugePages     int
+	HugePagesTotal    int
+	HugePagesFree     int
+	HugePagesRsvd     int
+	HugePagesSurp     int
+	Hugepagesize      int
+	DirectMap4k       int
+	DirectMap2M       int
+	DirectMap1G       int
+
+	// Extra holds values for /proc/meminfo keys registered via
+	// ExtraField, keyed by their /proc/meminfo name.  It's not part of
+	// the flatbuffers or protobuf wire formats; JSONEncoder includes it.
+	// It only exists for reading fields this package hasn't given a
+	// dedicated struct field.
+	Extra map[string]uint64
+}
+
+// field pairs the name of a /proc/meminfo line with the setter that
+// copies its value onto an *Info.
+type field struct {
+	name string
+	set  func(*Info, uint64)
+}
+
+// fields is the registry of known /proc/meminfo field names.  It is
+// consulted by both GetInfo and DataTicker, so registering a field once
+// makes it available at every parse site in this package.
+var fields = map[string]field{}
+
+// RegisterField registers a setter for the /proc/meminfo field with the
+// given name, so that GetInfo and DataTicker populate it on every future
+// call.  Registering a name that is already known replaces its setter,
+// which lets callers retarget a built-in field's value elsewhere as well
+// as pick up a name this package doesn't parse yet.  Since Info's struct
+// fields are fixed, a setter for a name with no dedicated field should
+// use ExtraField rather than trying to set one that doesn't exist.
+//
+// RegisterField is meant to be called from an init func, before any
+// goroutine starts reading meminfo; it does not synchronize access to the
+// registry.
+func RegisterField(name string, setter func(*Info, uint64)) {
+	fields[name] = field{name: name, set: setter}
+}
+
+// ExtraField returns a RegisterField setter that stores v in i.Extra
+// under name, allocating the map on first use.  Use it to pick up a
+// /proc/meminfo key this package has no dedicated Info field for:
+//
+//	mem.RegisterField("SomeNewKernelField", mem.ExtraField("SomeNewKernelField"))
+func ExtraField(name string) func(*Info, uint64) {
+	return func(i *Info, v uint64) {
+		if i.Extra == nil {
+			i.Extra = make(map[string]uint64)
+		}
+		i.Extra[name] = v
+	}
+}
+
+func init() {
+	RegisterField("MemTotal", func(i *Info, v uint64) { i.MemTotal = int(v) })
+	RegisterField("MemFree", func(i *Info, v uint64) { i.MemFree = int(v) })
+	RegisterField("MemAvailable", func(i *Info, v uint64) { i.MemAvailable = int(v) })
+	RegisterField("Buffers", func(i *Info, v uint64) { i.Buffers = int(v) })
+	RegisterField("Cached", func(i *Info, v uint64) { i.Cached = int(v) })
+	RegisterField("SwapCached", func(i *Info, v uint64) { i.SwapCached = int(v) })
+	RegisterField("Active", func(i *Info, v uint64) { i.Active = int(v) })
+	RegisterField("Inactive", func(i *Info, v uint64) { i.Inactive = int(v) })
+	RegisterField("Unevictable", func(i *Info, v uint64) { i.Unevictable = int(v) })
+	RegisterField("Mlocked", func(i *Info, v uint64) { i.Mlocked = int(v) })
+	RegisterField("SwapTotal", func(i *Info, v uint64) { i.SwapTotal = int(v) })
+	RegisterField("SwapFree", func(i *Info, v uint64) { i.SwapFree = int(v) })
+	RegisterField("Dirty", func(i *Info, v uint64) { i.Dirty = int(v) })
+	RegisterField("Writeback", func(i *Info, v uint64) { i.Writeback = int(v) })
+	RegisterField("AnonPages", func(i *Info, v uint64) { i.AnonPages = int(v) })
+	RegisterField("Mapped", func(i *Info, v uint64) { i.Mapped = int(v) })
+	RegisterField("Shmem", func(i *Info, v uint64) { i.Shmem = int(v) })
+	RegisterField("KReclaimable", func(i *Info, v uint64) { i.KReclaimable = int(v) })
+	RegisterField("Slab", func(i *Info, v uint64) { i.Slab = int(v) })
+	RegisterField("SReclaimable", func(i *Info, v uint64) { i.SReclaimable = int(v) })
+	RegisterField("SUnreclaim", func(i *Info, v uint64) { i.SUnreclaim = int(v) })
+	RegisterField("KernelStack", func(i *Info, v uint64) { i.KernelStack = int(v) })
+	RegisterField("PageTables", func(i *Info, v uint64) { i.PageTables = int(v) })
+	RegisterField("Bounce", func(i *Info, v uint64) { i.Bounce = int(v) })
+	RegisterField("CommitLimit", func(i *Info, v uint64) { i.CommitLimit = int(v) })
+	RegisterField("Committed_AS", func(i *Info, v uint64) { i.CommittedAS = int(v) })
+	RegisterField("VmallocTotal", func(i *Info, v uint64) { i.VmallocTotal = int(v) })
+	RegisterField("VmallocUsed", func(i *Info, v uint64) { i.VmallocUsed = int(v) })
+	RegisterField("VmallocChunk", func(i *Info, v uint64) { i.VmallocChunk = int(v) })
+	RegisterField("HardwareCorrupted", func(i *Info, v uint64) { i.HardwareCorrupted = int(v) })
+	RegisterField("AnonHugePages", func(i *Info, v uint64) { i.AnonHugePages = int(v) })
+	RegisterField("HugePages_Total", func(i *Info, v uint64) { i.HugePagesTotal = int(v) })
+	RegisterField("HugePages_Free", func(i *Info, v uint64) { i.HugePagesFree = int(v) })
+	RegisterField("HugePages_Rsvd", func(i *Info, v uint64) { i.HugePagesRsvd = int(v) })
+	RegisterField("HugePages_Surp", func(i *Info, v uint64) { i.HugePagesSurp = int(v) })
+	RegisterField("Hugepagesize", func(i *Info, v uint64) { i.Hugepagesize = int(v) })
+	RegisterField("DirectMap4k", func(i *Info, v uint64) { i.DirectMap4k = int(v) })
+	RegisterField("DirectMap2M", func(i *Info, v uint64) { i.DirectMap2M = int(v) })
+	RegisterField("DirectMap1G", func(i *Info, v uint64) { i.DirectMap1G = int(v) })
+}
+
+// serializeInto builds the flatbuffers-serialized Data for i using bldr.
+// It is the single place that knows how to map Info onto the generated
+// Data builder; both Serialize and DataTicker call through it so that
+// adding a built-in field is a one-line change here rather than a
+// change at every site that emits wire bytes.
+func serializeInto(bldr *fb.Builder, i *Info) []byte {
+	DataStart(bldr)
+	DataAddTimestamp(bldr, i.Timestamp)
+	DataAddMemTotal(bldr, int64(i.MemTotal))
+	DataAddMemFree(bldr, int64(i.MemFree))
+	DataAddMemAvailable(bldr, int64(i.MemAvailable))
+	DataAddBuffers(bldr, int64(i.Buffers))
+	DataAddCached(bldr, int64(i.Cached))
+	DataAddSwapCached(bldr, int64(i.SwapCached))
+	DataAddActive(bldr, int64(i.Active))
+	DataAddInactive(bldr, int64(i.Inactive))
+	DataAddUnevictable(bldr, int64(i.Unevictable))
+	DataAddMlocked(bldr, int64(i.Mlocked))
+	DataAddSwapTotal(bldr, int64(i.SwapTotal))
+	DataAddSwapFree(bldr, int64(i.SwapFree))
+	DataAddDirty(bldr, int64(i.Dirty))
+	DataAddWriteback(bldr, int64(i.Writeback))
+	DataAddAnonPages(bldr, int64(i.AnonPages))
+	DataAddMapped(bldr, int64(i.Mapped))
+	DataAddShmem(bldr, int64(i.Shmem))
+	DataAddKReclaimable(bldr, int64(i.KReclaimable))
+	DataAddSlab(bldr, int64(i.Slab))
+	DataAddSReclaimable(bldr, int64(i.SReclaimable))
+	DataAddSUnreclaim(bldr, int64(i.SUnreclaim))
+	DataAddKernelStack(bldr, int64(i.KernelStack))
+	DataAddPageTables(bldr, int64(i.PageTables))
+	DataAddBounce(bldr, int64(i.Bounce))
+	DataAddCommitLimit(bldr, int64(i.CommitLimit))
+	DataAddCommittedAS(bldr, int64(i.CommittedAS))
+	DataAddVmallocTotal(bldr, int64(i.VmallocTotal))
+	DataAddVmallocUsed(bldr, int64(i.VmallocUsed))
+	DataAddVmallocChunk(bldr, int64(i.VmallocChunk))
+	DataAddHardwareCorrupted(bldr, int64(i.HardwareCorrupted))
+	DataAddAnonHugePages(bldr, int64(i.AnonHugePages))
+	DataAddHugePagesTotal(bldr, int64(i.HugePagesTotal))
+	DataAddHugePagesFree(bldr, int64(i.HugePagesFree))
+	DataAddHugePagesRsvd(bldr, int64(i.HugePagesRsvd))
+	DataAddHugePagesSurp(bldr, int64(i.HugePagesSurp))
+	DataAddHugepagesize(bldr, int64(i.Hugepagesize))
+	DataAddDirectMap4k(bldr, int64(i.DirectMap4k))
+	DataAddDirectMap2M(bldr, int64(i.DirectMap2M))
+	DataAddDirectMap1G(bldr, int64(i.DirectMap1G))
+	bldr.Finish(DataEnd(bldr))
+	return bldr.Bytes[bldr.Head():]
 }
 
 // Serialize serializes the Info using flatbuffers.
 func (i *Info) Serialize() []byte {
-	builder := fb.NewBuilder(0)
-	DataStart(builder)
-	DataAddTimestamp(builder, int64(i.Timestamp))
-	DataAddMemTotal(builder, int64(i.MemTotal))
-	DataAddMemFree(builder, int64(i.MemFree))
-	DataAddMemAvailable(builder, int64(i.MemAvailable))
-	DataAddBuffers(builder, int64(i.Buffers))
-	DataAddCached(builder, int64(i.Cached))
-	DataAddSwapCached(builder, int64(i.SwapCached))
-	DataAddActive(builder, int64(i.Active))
-	DataAddInactive(builder, int64(i.Inactive))
-	DataAddSwapTotal(builder, int64(i.SwapTotal))
-	DataAddSwapFree(builder, int64(i.SwapFree))
-	builder.Finish(DataEnd(builder))
-	return builder.Bytes[builder.Head():]
+	return serializeInto(fb.NewBuilder(0), i)
 }
 
 // Deserialize deserializes bytes representing flatbuffers serialized Data
@@ -79,8 +236,37 @@ func Deserialize(p []byte) *Info {
 	info.SwapCached = int(data.SwapCached())
 	info.Active = int(data.Active())
 	info.Inactive = int(data.Inactive())
+	info.Unevictable = int(data.Unevictable())
+	info.Mlocked = int(data.Mlocked())
 	info.SwapTotal = int(data.SwapTotal())
 	info.SwapFree = int(data.SwapFree())
+	info.Dirty = int(data.Dirty())
+	info.Writeback = int(data.Writeback())
+	info.AnonPages = int(data.AnonPages())
+	info.Mapped = int(data.Mapped())
+	info.Shmem = int(data.Shmem())
+	info.KReclaimable = int(data.KReclaimable())
+	info.Slab = int(data.Slab())
+	info.SReclaimable = int(data.SReclaimable())
+	info.SUnreclaim = int(data.SUnreclaim())
+	info.KernelStack = int(data.KernelStack())
+	info.PageTables = int(data.PageTables())
+	info.Bounce = int(data.Bounce())
+	info.CommitLimit = int(data.CommitLimit())
+	info.CommittedAS = int(data.CommittedAS())
+	info.VmallocTotal = int(data.VmallocTotal())
+	info.VmallocUsed = int(data.VmallocUsed())
+	info.VmallocChunk = int(data.VmallocChunk())
+	info.HardwareCorrupted = int(data.HardwareCorrupted())
+	info.AnonHugePages = int(data.AnonHugePages())
+	info.HugePagesTotal = int(data.HugePagesTotal())
+	info.HugePagesFree = int(data.HugePagesFree())
+	info.HugePagesRsvd = int(data.HugePagesRsvd())
+	info.HugePagesSurp = int(data.HugePagesSurp())
+	info.Hugepagesize = int(data.Hugepagesize())
+	info.DirectMap4k = int(data.DirectMap4k())
+	info.DirectMap2M = int(data.DirectMap2M())
+	info.DirectMap1G = int(data.DirectMap1G())
 	return info
 }
 
@@ -88,30 +274,12 @@ func (d *Info) String() string {
 	return fmt.Sprintf("Timestamp: %v\nMemTotal:\t%d\tMemFree:\t%d\tMemAvailable:\t%d\tActive:\t%d\tInactive:\t%d\nCached:\t\t%d\tBuffers\t:%d\nSwapTotal:\t%d\tSwapCached:\t%d\tSwapFree:\t%d\n", time.Unix(0, d.Timestamp).UTC(), d.MemTotal, d.MemFree, d.MemAvailable, d.Active, d.Inactive, d.Cached, d.Buffers, d.SwapTotal, d.SwapCached, d.SwapFree)
 }
 
-var (
-	memTotal     = []byte("MemTotal")
-	memFree      = []byte("MemFree")
-	memAvailable = []byte("MemAvailable")
-	buffers      = []byte("Buffers")
-	cached       = []byte("Cached")
-	swapCached   = []byte("SwapCached")
-	active       = []byte("Active")
-	inactive     = []byte("Inactive")
-	swapTotal    = []byte("SwapTotal")
-	swapFree     = []byte("SwapFree")
-)
-
-// GetInfo returns some of the results of /proc/meminfo.
-func GetInfo() (*Info, error) {
-	buf, err := ioutil.ReadFile("/proc/meminfo")
-	if err != nil {
-		return nil, err
-	}
-
-	f := Info{
-		Timestamp: time.Now().UTC().UnixNano(),
-	}
-	for p := 0; ; {
+// parse decodes the /proc/meminfo contents in buf into f, dispatching
+// each "Name: value" line through the fields registry.  Names that
+// aren't registered are ignored, which is what lets newer kernels add
+// fields this package doesn't know about without breaking parsing.
+func parse(buf []byte, f *Info) error {
+	for p := 0; p < len(buf); {
 		// Skip to the colon.
 		o := bytes.IndexByte(buf[p:], ':')
 		if o < 0 {
@@ -135,188 +303,103 @@ func GetInfo() (*Info, error) {
 		}
 		v, err := helpers.ParseUint(buf[p:i])
 		if err != nil {
-			return nil, err
+			return err
 		}
 		// Skip to the end.
-		p = i + bytes.IndexByte(buf[i:], '\n') + 1
+		nl := bytes.IndexByte(buf[i:], '\n')
+		if nl < 0 {
+			p = len(buf)
+		} else {
+			p = i + nl + 1
+		}
 
-		switch {
-		case bytes.Equal(name, memTotal):
-			f.MemTotal = int(v)
-		case bytes.Equal(name, memFree):
-			f.MemFree = int(v)
-		case bytes.Equal(name, memAvailable):
-			f.MemAvailable = int(v)
-		case bytes.Equal(name, buffers):
-			f.Buffers = int(v)
-		case bytes.Equal(name, cached):
-			f.Cached = int(v)
-		case bytes.Equal(name, swapCached):
-			f.SwapCached = int(v)
-		case bytes.Equal(name, active):
-			f.Active = int(v)
-		case bytes.Equal(name, inactive):
-			f.Inactive = int(v)
-		case bytes.Equal(name, swapTotal):
-			f.SwapTotal = int(v)
-		case bytes.Equal(name, swapFree):
-			f.SwapFree = int(v)
+		if fld, ok := fields[string(name)]; ok {
+			fld.set(f, v)
 		}
 	}
+	return nil
+}
+
+// GetInfo returns the results of /proc/meminfo.
+func GetInfo() (*Info, error) {
+	buf, err := ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+
+	f := Info{
+		Timestamp: time.Now().UTC().UnixNano(),
+	}
+	if err := parse(buf, &f); err != nil {
+		return nil, err
+	}
 	return &f, nil
 }
 
-// GetData returns the current meminfo as flatbuffer serialized bytes.
-func GetData() ([]byte, error) {
+// GetData returns the current meminfo encoded with enc.
+func GetData(enc Encoder) ([]byte, error) {
 	inf, err := GetInfo()
 	if err != nil {
 		return nil, err
 	}
-	return inf.Serialize(), nil
+	return enc.Encode(inf), nil
 }
 
 // DataTicker gathers the meminfo on a ticker, whose interval is defined by
-// the received duration, and sends the results to the channel.  The output
-// is Flatbuffers serialized Data.  Any error encountered during processing
-// is sent to the error channel.  Processing will continue
+// the received duration, and sends the results, encoded with enc, to the
+// channel.  Any error encountered during processing is sent to the error
+// channel.  Processing will continue.
 //
 // Either closing the done channel or sending struct{} to the done channel
 // will result in function exit.  The out channel is closed on exit.
 //
-// This pre-allocates the builder and everything other than the []byte that
-// gets sent to the out channel to reduce allocations, as this is expected
-// to be both a frequent and a long-running process.  Doing so reduces
-// byte allocations per tick just ~ 42%.
-func DataTicker(interval time.Duration, outCh chan []byte, done chan struct{}, errCh chan error) {
+// Parsing is driven by the same fields registry as GetInfo.  When enc is
+// a FlatbuffersEncoder, DataTicker reuses a single flatbuffers builder
+// across ticks instead of going through Info.Serialize's per-call
+// fb.NewBuilder, since that's the encoder most callers use and it's
+// meant to matter on what's expected to be a long-running process;
+// other encoders pay one allocation per tick for their own Encode.
+//
+// If hist is non-nil, every sample is also recorded with hist.Add, so a
+// caller can keep answering range/downsample queries against recent
+// history without building its own buffer around outCh.  hist may be
+// nil.
+func DataTicker(interval time.Duration, enc Encoder, hist *History, outCh chan []byte, done chan struct{}, errCh chan error) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	defer close(outCh)
-	// predeclare some vars
-	var l, i, pos int
-	var t int64
-	var v byte
-	var name string
-	// premake some temp slices
-	val := make([]byte, 0, 32)
-	// just reset the bldr at the end of every ticker
+
+	_, flat := enc.(FlatbuffersEncoder)
 	bldr := fb.NewBuilder(0)
-	// Some hopes to jump through to ensure we don't get a ErrBufferFull; which was
-	// occuring with var buf bufio.Reader (which works in the bench code)
-	var bs []byte
-	tmp := bytes.NewBuffer(bs)
-	buf := bufio.NewReaderSize(tmp, 1536)
-	tmp = nil
-	// ticker
+
 	for {
 		select {
 		case <-done:
 			return
 		case <-ticker.C:
-			// The current timestamp is always in UTC
-			t = time.Now().UTC().UnixNano()
-			f, err := os.Open("/proc/meminfo")
+			t := time.Now().UTC().UnixNano()
+			raw, err := ioutil.ReadFile("/proc/meminfo")
 			if err != nil {
 				errCh <- joe.Error{Type: "mem", Op: "open /proc/meminfo", Err: err}
 				continue
 			}
-			buf.Reset(f)
-			DataStart(bldr)
-			DataAddTimestamp(bldr, t)
-			for {
-				if l == 16 {
-					break
-				}
-				line, err := buf.ReadSlice('\n')
-				if err != nil {
-					if err == io.EOF {
-						break
-					}
-					errCh <- joe.Error{Type: "mem", Op: "read command results", Err: err}
-					break
-				}
-				l++
-				if l > 8 && l < 15 {
-					continue
-				}
-				// first grab the key name (everything up to the ':')
-				for i, v = range line {
-					if v == 0x3A {
-						pos = i + 1
-						break
-					}
-					val = append(val, v)
-				}
-				name = string(val[:])
-				val = val[:0]
-				// skip all spaces
-				for i, v = range line[pos:] {
-					if v != 0x20 {
-						pos += i
-						break
-					}
-				}
 
-				// grab the numbers
-				for _, v = range line[pos:] {
-					if v == 0x20 || v == '\r' {
-						break
-					}
-					val = append(val, v)
-				}
-				// any conversion error results in 0
-				i, err = strconv.Atoi(string(val[:]))
-				if err != nil {
-					errCh <- joe.Error{Type: "mem", Op: "convert to int", Err: err}
-					continue
-				}
-				val = val[:0]
-				if name == "MemTotal" {
-					DataAddMemTotal(bldr, int64(i))
-					continue
-				}
-				if name == "MemFree" {
-					DataAddMemFree(bldr, int64(i))
-					continue
-				}
-				if name == "MemAvailable" {
-					DataAddMemAvailable(bldr, int64(i))
-					continue
-				}
-				if name == "Buffers" {
-					DataAddBuffers(bldr, int64(i))
-					continue
-				}
-				if name == "Cached" {
-					DataAddMemAvailable(bldr, int64(i))
-					continue
-				}
-				if name == "SwapCached" {
-					DataAddSwapCached(bldr, int64(i))
-					continue
-				}
-				if name == "Active" {
-					DataAddActive(bldr, int64(i))
-					continue
-				}
-				if name == "Inactive" {
-					DataAddInactive(bldr, int64(i))
-					continue
-				}
-				if name == "SwapTotal" {
-					DataAddSwapTotal(bldr, int64(i))
-					continue
-				}
-				if name == "SwapFree" {
-					DataAddSwapFree(bldr, int64(i))
-					continue
-				}
+			f := &Info{Timestamp: t}
+			if err := parse(raw, f); err != nil {
+				errCh <- joe.Error{Type: "mem", Op: "parse /proc/meminfo", Err: err}
+				continue
+			}
+
+			if hist != nil {
+				hist.Add(f)
+			}
+
+			if flat {
+				bldr.Reset()
+				outCh <- serializeInto(bldr, f)
+				continue
 			}
-			f.Close()
-			bldr.Finish(DataEnd(bldr))
-			data := bldr.Bytes[bldr.Head():]
-			outCh <- data
-			bldr.Reset()
-			l = 0
+			outCh <- enc.Encode(f)
 		}
 	}
 }
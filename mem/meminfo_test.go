@@ -0,0 +1,138 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mem
+
+import (
+	"reflect"
+	"testing"
+)
+
+const sampleMeminfo = `MemTotal:       16313496 kB
+MemFree:         8214092 kB
+MemAvailable:   12345678 kB
+Buffers:          123456 kB
+Cached:          1234567 kB
+SwapCached:            0 kB
+Active:          2345678 kB
+Inactive:        1456789 kB
+Unevictable:        1024 kB
+Mlocked:               0 kB
+SwapTotal:       2097148 kB
+SwapFree:        2097148 kB
+Dirty:               128 kB
+Writeback:             0 kB
+AnonPages:       1456789 kB
+Mapped:           234567 kB
+Shmem:             12345 kB
+KReclaimable:     123456 kB
+Slab:             234567 kB
+SReclaimable:     123456 kB
+SUnreclaim:       111111 kB
+KernelStack:        8192 kB
+PageTables:        16384 kB
+Bounce:                0 kB
+CommitLimit:    10000000 kB
+Committed_AS:    3456789 kB
+VmallocTotal:   34359738367 kB
+VmallocUsed:       123456 kB
+VmallocChunk:           0 kB
+HardwareCorrupted:      0 kB
+AnonHugePages:          0 kB
+HugePages_Total:       0
+HugePages_Free:        0
+HugePages_Rsvd:        0
+HugePages_Surp:        0
+Hugepagesize:       2048 kB
+DirectMap4k:      123456 kB
+DirectMap2M:     4194304 kB
+DirectMap1G:           0 kB
+`
+
+func TestParse(t *testing.T) {
+	f := &Info{}
+	if err := parse([]byte(sampleMeminfo), f); err != nil {
+		t.Fatalf("parse: unexpected error: %v", err)
+	}
+
+	want := Info{
+		MemTotal:          16313496,
+		MemFree:           8214092,
+		MemAvailable:      12345678,
+		Buffers:           123456,
+		Cached:            1234567,
+		SwapCached:        0,
+		Active:            2345678,
+		Inactive:          1456789,
+		Unevictable:       1024,
+		Mlocked:           0,
+		SwapTotal:         2097148,
+		SwapFree:          2097148,
+		Dirty:             128,
+		Writeback:         0,
+		AnonPages:         1456789,
+		Mapped:            234567,
+		Shmem:             12345,
+		KReclaimable:      123456,
+		Slab:              234567,
+		SReclaimable:      123456,
+		SUnreclaim:        111111,
+		KernelStack:       8192,
+		PageTables:        16384,
+		Bounce:            0,
+		CommitLimit:       10000000,
+		CommittedAS:       3456789,
+		VmallocTotal:      34359738367,
+		VmallocUsed:       123456,
+		VmallocChunk:      0,
+		HardwareCorrupted: 0,
+		AnonHugePages:     0,
+		Hugepagesize:      2048,
+		DirectMap4k:       123456,
+		DirectMap2M:       4194304,
+		DirectMap1G:       0,
+	}
+	want.Timestamp = f.Timestamp // not under test here
+
+	if !reflect.DeepEqual(*f, want) {
+		t.Errorf("parse got %+v, want %+v", *f, want)
+	}
+}
+
+func TestExtraField(t *testing.T) {
+	RegisterField("SomeNewKernelField", ExtraField("SomeNewKernelField"))
+	defer delete(fields, "SomeNewKernelField")
+
+	f := &Info{}
+	buf := []byte("MemTotal:       16313496 kB\nSomeNewKernelField: 42 kB\n")
+	if err := parse(buf, f); err != nil {
+		t.Fatalf("parse: unexpected error: %v", err)
+	}
+	if f.MemTotal != 16313496 {
+		t.Errorf("MemTotal = %d, want 16313496", f.MemTotal)
+	}
+	if got := f.Extra["SomeNewKernelField"]; got != 42 {
+		t.Errorf("Extra[SomeNewKernelField] = %d, want 42", got)
+	}
+}
+
+func TestParseUnknownFieldIgnored(t *testing.T) {
+	f := &Info{}
+	buf := []byte("MemTotal:       16313496 kB\nSomeFutureKernelField: 42 kB\nMemFree:         8214092 kB\n")
+	if err := parse(buf, f); err != nil {
+		t.Fatalf("parse: unexpected error: %v", err)
+	}
+	if f.MemTotal != 16313496 || f.MemFree != 8214092 {
+		t.Errorf("parse got MemTotal=%d MemFree=%d, want 16313496, 8214092", f.MemTotal, f.MemFree)
+	}
+}
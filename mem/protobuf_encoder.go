@@ -0,0 +1,130 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mem
+
+import "github.com/mohae/joefriday/mem/pb"
+
+// ProtobufEncoder encodes an Info as the gogo/protobuf-generated
+// pb.Data message defined in mem/pb/data.proto.
+type ProtobufEncoder struct{}
+
+// Encode implements Encoder.  A Marshal error from a well-formed,
+// generated proto.Message is a programmer error, so Encode panics
+// rather than returning an error Encoder.Encode has no way to report.
+func (ProtobufEncoder) Encode(i *Info) []byte {
+	p, err := infoToPB(i).Marshal()
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Decode implements Encoder.
+func (ProtobufEncoder) Decode(p []byte) (*Info, error) {
+	d := &pb.Data{}
+	if err := d.Unmarshal(p); err != nil {
+		return nil, err
+	}
+	return pbToInfo(d), nil
+}
+
+func infoToPB(i *Info) *pb.Data {
+	return &pb.Data{
+		Timestamp:         i.Timestamp,
+		MemTotal:          int64(i.MemTotal),
+		MemFree:           int64(i.MemFree),
+		MemAvailable:      int64(i.MemAvailable),
+		Buffers:           int64(i.Buffers),
+		Cached:            int64(i.Cached),
+		SwapCached:        int64(i.SwapCached),
+		Active:            int64(i.Active),
+		Inactive:          int64(i.Inactive),
+		Unevictable:       int64(i.Unevictable),
+		Mlocked:           int64(i.Mlocked),
+		SwapTotal:         int64(i.SwapTotal),
+		SwapFree:          int64(i.SwapFree),
+		Dirty:             int64(i.Dirty),
+		Writeback:         int64(i.Writeback),
+		AnonPages:         int64(i.AnonPages),
+		Mapped:            int64(i.Mapped),
+		Shmem:             int64(i.Shmem),
+		KReclaimable:      int64(i.KReclaimable),
+		Slab:              int64(i.Slab),
+		SReclaimable:      int64(i.SReclaimable),
+		SUnreclaim:        int64(i.SUnreclaim),
+		KernelStack:       int64(i.KernelStack),
+		PageTables:        int64(i.PageTables),
+		Bounce:            int64(i.Bounce),
+		CommitLimit:       int64(i.CommitLimit),
+		CommittedAS:       int64(i.CommittedAS),
+		VmallocTotal:      int64(i.VmallocTotal),
+		VmallocUsed:       int64(i.VmallocUsed),
+		VmallocChunk:      int64(i.VmallocChunk),
+		HardwareCorrupted: int64(i.HardwareCorrupted),
+		AnonHugePages:     int64(i.AnonHugePages),
+		HugePagesTotal:    int64(i.HugePagesTotal),
+		HugePagesFree:     int64(i.HugePagesFree),
+		HugePagesRsvd:     int64(i.HugePagesRsvd),
+		HugePagesSurp:     int64(i.HugePagesSurp),
+		Hugepagesize:      int64(i.Hugepagesize),
+		DirectMap4k:       int64(i.DirectMap4k),
+		DirectMap2M:       int64(i.DirectMap2M),
+		DirectMap1G:       int64(i.DirectMap1G),
+	}
+}
+
+func pbToInfo(d *pb.Data) *Info {
+	return &Info{
+		Timestamp:         d.Timestamp,
+		MemTotal:          int(d.MemTotal),
+		MemFree:           int(d.MemFree),
+		MemAvailable:      int(d.MemAvailable),
+		Buffers:           int(d.Buffers),
+		Cached:            int(d.Cached),
+		SwapCached:        int(d.SwapCached),
+		Active:            int(d.Active),
+		Inactive:          int(d.Inactive),
+		Unevictable:       int(d.Unevictable),
+		Mlocked:           int(d.Mlocked),
+		SwapTotal:         int(d.SwapTotal),
+		SwapFree:          int(d.SwapFree),
+		Dirty:             int(d.Dirty),
+		Writeback:         int(d.Writeback),
+		AnonPages:         int(d.AnonPages),
+		Mapped:            int(d.Mapped),
+		Shmem:             int(d.Shmem),
+		KReclaimable:      int(d.KReclaimable),
+		Slab:              int(d.Slab),
+		SReclaimable:      int(d.SReclaimable),
+		SUnreclaim:        int(d.SUnreclaim),
+		KernelStack:       int(d.KernelStack),
+		PageTables:        int(d.PageTables),
+		Bounce:            int(d.Bounce),
+		CommitLimit:       int(d.CommitLimit),
+		CommittedAS:       int(d.CommittedAS),
+		VmallocTotal:      int(d.VmallocTotal),
+		VmallocUsed:       int(d.VmallocUsed),
+		VmallocChunk:      int(d.VmallocChunk),
+		HardwareCorrupted: int(d.HardwareCorrupted),
+		AnonHugePages:     int(d.AnonHugePages),
+		HugePagesTotal:    int(d.HugePagesTotal),
+		HugePagesFree:     int(d.HugePagesFree),
+		HugePagesRsvd:     int(d.HugePagesRsvd),
+		HugePagesSurp:     int(d.HugePagesSurp),
+		Hugepagesize:      int(d.Hugepagesize),
+		DirectMap4k:       int(d.DirectMap4k),
+		DirectMap2M:       int(d.DirectMap2M),
+		DirectMap1G:       int(d.DirectMap1G),
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mem
+
+import (
+	"io/ioutil"
+	"time"
+
+	fb "github.com/google/flatbuffers/go"
+	joe "github.com/mohae/joefriday"
+)
+
+// Delta holds how much select Info fields changed between two
+// consecutive RateTicker samples, plus the pressure indicators that are
+// meaningful from a single sample.
+type Delta struct {
+	// Timestamp is the newer sample's timestamp.
+	Timestamp int64
+	// Interval is the time between the two samples the delta was
+	// computed from.
+	Interval time.Duration
+
+	// MemFreeDelta and CachedDelta are the raw change in MemFree and
+	// Cached since the previous sample.
+	MemFreeDelta int
+	CachedDelta  int
+
+	// SwapFreeDelta is the raw change in SwapFree since the previous
+	// sample: negative means swap usage grew (the kernel pushed pages
+	// out to swap), positive means it shrank (pages were paged back
+	// in). SwapInOutRate is SwapFreeDelta normalized to a per-second
+	// rate.
+	SwapFreeDelta int
+	SwapInOutRate float64
+
+	// CommitPressure is CommittedAS - CommitLimit for the newer
+	// sample: positive means the kernel has already overcommitted more
+	// virtual memory than CommitLimit allows, a leading indicator of
+	// allocation pressure that shows up before swap does.
+	CommitPressure int
+}
+
+// delta computes the Delta between prev and cur, using the actual
+// elapsed time between their timestamps rather than the ticker's nominal
+// interval, since a slow consumer or a missed tick can make the two
+// diverge.
+func delta(prev, cur *Info) *Delta {
+	interval := time.Duration(cur.Timestamp - prev.Timestamp)
+	d := &Delta{
+		Timestamp:      cur.Timestamp,
+		Interval:       interval,
+		MemFreeDelta:   cur.MemFree - prev.MemFree,
+		CachedDelta:    cur.Cached - prev.Cached,
+		SwapFreeDelta:  cur.SwapFree - prev.SwapFree,
+		CommitPressure: cur.CommittedAS - cur.CommitLimit,
+	}
+	if secs := interval.Seconds(); secs > 0 {
+		d.SwapInOutRate = float64(d.SwapFreeDelta) / secs
+	}
+	return d
+}
+
+// RateTicker gathers the meminfo on a ticker, the same way DataTicker
+// does, and sends the snapshot, encoded with enc, to outCh.  It also
+// keeps the previous sample so that, starting with the second tick, it
+// can send a Delta for the pair to deltaCh.  If hist is non-nil, every
+// sample is also recorded with hist.Add.
+//
+// Either closing the done channel or sending struct{} to the done
+// channel will result in function exit.  Both outCh and deltaCh are
+// closed on exit.
+func RateTicker(interval time.Duration, enc Encoder, hist *History, outCh chan []byte, deltaCh chan *Delta, done chan struct{}, errCh chan error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer close(outCh)
+	defer close(deltaCh)
+
+	_, flat := enc.(FlatbuffersEncoder)
+	bldr := fb.NewBuilder(0)
+
+	var prev *Info
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			t := time.Now().UTC().UnixNano()
+			raw, err := ioutil.ReadFile("/proc/meminfo")
+			if err != nil {
+				errCh <- joe.Error{Type: "mem", Op: "open /proc/meminfo", Err: err}
+				continue
+			}
+
+			f := &Info{Timestamp: t}
+			if err := parse(raw, f); err != nil {
+				errCh <- joe.Error{Type: "mem", Op: "parse /proc/meminfo", Err: err}
+				continue
+			}
+
+			if hist != nil {
+				hist.Add(f)
+			}
+			if prev != nil {
+				deltaCh <- delta(prev, f)
+			}
+			prev = f
+
+			if flat {
+				bldr.Reset()
+				outCh <- serializeInto(bldr, f)
+				continue
+			}
+			outCh <- enc.Encode(f)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mem
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelta(t *testing.T) {
+	prev := &Info{
+		Timestamp:   0,
+		MemFree:     1000,
+		Cached:      500,
+		SwapFree:    2000,
+		CommittedAS: 100,
+		CommitLimit: 900,
+	}
+	cur := &Info{
+		Timestamp:   int64(2 * time.Second),
+		MemFree:     800,
+		Cached:      600,
+		SwapFree:    1500,
+		CommittedAS: 950,
+		CommitLimit: 900,
+	}
+
+	d := delta(prev, cur)
+
+	if d.Timestamp != cur.Timestamp {
+		t.Errorf("Timestamp = %d, want %d", d.Timestamp, cur.Timestamp)
+	}
+	if d.Interval != 2*time.Second {
+		t.Errorf("Interval = %v, want 2s", d.Interval)
+	}
+	if d.MemFreeDelta != -200 {
+		t.Errorf("MemFreeDelta = %d, want -200", d.MemFreeDelta)
+	}
+	if d.CachedDelta != 100 {
+		t.Errorf("CachedDelta = %d, want 100", d.CachedDelta)
+	}
+	if d.SwapFreeDelta != -500 {
+		t.Errorf("SwapFreeDelta = %d, want -500", d.SwapFreeDelta)
+	}
+	if d.SwapInOutRate != -250 {
+		t.Errorf("SwapInOutRate = %v, want -250", d.SwapInOutRate)
+	}
+	if d.CommitPressure != 50 {
+		t.Errorf("CommitPressure = %d, want 50", d.CommitPressure)
+	}
+}
+
+// TestDeltaUsesActualElapsedTime guards against using a nominal ticker
+// interval instead of the samples' own timestamps: here the samples are
+// 4s apart even though a caller might be ticking every 1s.
+func TestDeltaUsesActualElapsedTime(t *testing.T) {
+	prev := &Info{Timestamp: 0, SwapFree: 1000}
+	cur := &Info{Timestamp: int64(4 * time.Second), SwapFree: 600}
+
+	d := delta(prev, cur)
+
+	if d.Interval != 4*time.Second {
+		t.Errorf("Interval = %v, want 4s (actual elapsed, not the nominal tick interval)", d.Interval)
+	}
+	if d.SwapInOutRate != -100 {
+		t.Errorf("SwapInOutRate = %v, want -100", d.SwapInOutRate)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafka publishes the ticks a mem.DataTicker (or mem.Source,
+// e.g. mem/cgroup) produces to a Kafka topic.
+package kafka
+
+import (
+	"os"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Config holds the settings for a Sink.
+type Config struct {
+	// Brokers is the Kafka bootstrap broker list.
+	Brokers []string
+	// Topic is the topic ticks are published to.
+	Topic string
+	// Hostname is the partition key.  It defaults to os.Hostname() when
+	// empty.
+	Hostname string
+	// RequiredAcks controls how many replicas must acknowledge a
+	// publish before sarama considers it successful.  A nil value
+	// defaults to sarama.WaitForLocal (the leader only); this is a
+	// pointer so that an explicit sarama.NoResponse, which is the zero
+	// value, is distinguishable from "unset".
+	RequiredAcks *sarama.RequiredAcks
+	// Compression is applied to each published message.  A nil value
+	// defaults to sarama.CompressionSnappy; this is a pointer so that
+	// an explicit sarama.CompressionNone, which is the zero value, is
+	// distinguishable from "unset".
+	Compression *sarama.CompressionCodec
+	// BatchSize is the number of ticks to accumulate before flushing,
+	// a la Nagle's algorithm.  It defaults to 100.
+	BatchSize int
+	// BatchInterval is the longest a tick will wait in the batch
+	// before being flushed, regardless of BatchSize.  It defaults to
+	// time.Second.
+	BatchInterval time.Duration
+}
+
+func (c *Config) setDefaults() error {
+	if c.Hostname == "" {
+		h, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		c.Hostname = h
+	}
+	if c.RequiredAcks == nil {
+		acks := sarama.WaitForLocal
+		c.RequiredAcks = &acks
+	}
+	if c.Compression == nil {
+		comp := sarama.CompressionSnappy
+		c.Compression = &comp
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 100
+	}
+	if c.BatchInterval == 0 {
+		c.BatchInterval = time.Second
+	}
+	return nil
+}
+
+// Sink batches ticks read from a mem.DataTicker outCh and publishes them
+// to Kafka using a sarama async producer.
+type Sink struct {
+	cfg      Config
+	producer sarama.AsyncProducer
+
+	// DeadLetter receives the raw tick bytes for any publish Kafka
+	// rejected.  Callers that don't care can leave it unread; it's
+	// buffered so a slow or absent reader doesn't block the flush
+	// goroutine, but a sink that publishes faster than DeadLetter is
+	// drained will start dropping the oldest dead-lettered tick rather
+	// than block.
+	DeadLetter chan []byte
+
+	done chan struct{}
+}
+
+// New builds a Sink from cfg, connecting a sarama async producer to
+// cfg.Brokers.
+func New(cfg Config) (*Sink, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+
+	scfg := sarama.NewConfig()
+	scfg.Producer.RequiredAcks = *cfg.RequiredAcks
+	scfg.Producer.Compression = *cfg.Compression
+	scfg.Producer.Return.Successes = false
+	scfg.Producer.Return.Errors = true
+
+	producer, err := sarama.NewAsyncProducer(cfg.Brokers, scfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sink{
+		cfg:        cfg,
+		producer:   producer,
+		DeadLetter: make(chan []byte, cfg.BatchSize),
+		done:       make(chan struct{}),
+	}
+	go s.drainErrors()
+	return s, nil
+}
+
+// drainErrors forwards every publish error sarama reports to
+// DeadLetter, dropping the oldest pending dead letter rather than
+// blocking if the caller isn't reading DeadLetter.
+func (s *Sink) drainErrors() {
+	for perr := range s.producer.Errors() {
+		tick, ok := perr.Msg.Value.(sarama.ByteEncoder)
+		if !ok {
+			continue
+		}
+		select {
+		case s.DeadLetter <- []byte(tick):
+		default:
+			select {
+			case <-s.DeadLetter:
+			default:
+			}
+			select {
+			case s.DeadLetter <- []byte(tick):
+			default:
+			}
+		}
+	}
+}
+
+// Run reads ticks from inCh and publishes them to cfg.Topic, flushing
+// every cfg.BatchSize ticks or every cfg.BatchInterval, whichever comes
+// first.  Run returns when inCh is closed, after flushing whatever is
+// left in the batch and closing the underlying producer.
+func (s *Sink) Run(inCh <-chan []byte) {
+	defer close(s.done)
+	defer s.producer.Close()
+
+	t := time.NewTimer(s.cfg.BatchInterval)
+	defer t.Stop()
+
+	batch := make([]sarama.ProducerMessage, 0, s.cfg.BatchSize)
+	flush := func() {
+		for _, m := range batch {
+			m := m // fresh copy: batch's backing array is reused after flush
+			s.producer.Input() <- &m
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case tick, ok := <-inCh:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, sarama.ProducerMessage{
+				Topic: s.cfg.Topic,
+				Key:   sarama.StringEncoder(s.cfg.Hostname),
+				Value: sarama.ByteEncoder(tick),
+			})
+			if len(batch) >= s.cfg.BatchSize {
+				flush()
+				// Stop and drain before Reset: t.C may already have
+				// a pending fire from the interval that was running,
+				// and reusing a Timer without doing so is unsafe.
+				if !t.Stop() {
+					<-t.C
+				}
+				t.Reset(s.cfg.BatchInterval)
+			}
+		case <-t.C:
+			flush()
+			t.Reset(s.cfg.BatchInterval)
+		}
+	}
+}
+
+// Close waits for Run to finish flushing; it does not stop Run itself.
+// Callers should close their own outCh/done first so Run's inCh drains
+// and returns; Close then blocks until that happens.
+func (s *Sink) Close() {
+	<-s.done
+}
@@ -0,0 +1,55 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestConfigSetDefaults(t *testing.T) {
+	c := &Config{Hostname: "host"}
+	if err := c.setDefaults(); err != nil {
+		t.Fatalf("setDefaults: unexpected error: %v", err)
+	}
+	if *c.RequiredAcks != sarama.WaitForLocal {
+		t.Errorf("RequiredAcks = %v, want WaitForLocal", *c.RequiredAcks)
+	}
+	if *c.Compression != sarama.CompressionSnappy {
+		t.Errorf("Compression = %v, want CompressionSnappy", *c.Compression)
+	}
+	if c.BatchSize != 100 {
+		t.Errorf("BatchSize = %d, want 100", c.BatchSize)
+	}
+	if c.BatchInterval != time.Second {
+		t.Errorf("BatchInterval = %v, want 1s", c.BatchInterval)
+	}
+}
+
+func TestConfigSetDefaultsExplicitZeroValues(t *testing.T) {
+	acks := sarama.NoResponse
+	comp := sarama.CompressionNone
+	c := &Config{Hostname: "host", RequiredAcks: &acks, Compression: &comp}
+	if err := c.setDefaults(); err != nil {
+		t.Fatalf("setDefaults: unexpected error: %v", err)
+	}
+	if *c.RequiredAcks != sarama.NoResponse {
+		t.Errorf("RequiredAcks = %v, want NoResponse to be preserved", *c.RequiredAcks)
+	}
+	if *c.Compression != sarama.CompressionNone {
+		t.Errorf("Compression = %v, want CompressionNone to be preserved", *c.Compression)
+	}
+}
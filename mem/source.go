@@ -0,0 +1,45 @@
+// Copyright 2016 Joel Scoble and The JoeFriday authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mem
+
+import "time"
+
+// Source is a readable supply of memory info.  GetData returns a single
+// snapshot encoded with enc; DataTicker streams snapshots on the
+// given interval the same way the package-level DataTicker does.
+//
+// /proc/meminfo, via GetData and DataTicker, is the default Source.
+// mem/cgroup is another: it satisfies Source by reading the calling
+// process's own cgroup instead of the host-wide view in /proc/meminfo,
+// so a consumer that's been reading from a chan []byte doesn't need to
+// change at all to start reading cgroup-scoped memory info instead.
+type Source interface {
+	GetData(enc Encoder) ([]byte, error)
+	DataTicker(interval time.Duration, enc Encoder, hist *History, outCh chan []byte, done chan struct{}, errCh chan error)
+}
+
+// procSource is the Source backed by /proc/meminfo.
+type procSource struct{}
+
+func (procSource) GetData(enc Encoder) ([]byte, error) { return GetData(enc) }
+
+func (procSource) DataTicker(interval time.Duration, enc Encoder, hist *History, outCh chan []byte, done chan struct{}, errCh chan error) {
+	DataTicker(interval, enc, hist, outCh, done, errCh)
+}
+
+// Proc is the Source backed by /proc/meminfo, i.e. the host-wide view
+// that GetData and DataTicker already provide.  It exists so that code
+// written against the Source interface can use the default
+// implementation without special-casing it.
+var Proc Source = procSource{}